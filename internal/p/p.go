@@ -0,0 +1,10 @@
+// Package p is testdata: a handful of named types whose underlying types are
+// string/[]byte/[]rune/byte/rune, used by internal/all.go to exercise the
+// "named type with the same underlying type" conversion cases.
+package p
+
+type String string
+type Bytes []byte
+type Runes []rune
+type Byte byte
+type Rune rune
@@ -1,5 +1,10 @@
-// This is testdata but named internal so go list returns it from the parent directory.
-package internal
+// Package testdata is a dogfood fixture for stringconv: running this tool
+// against it should report the counts noted in each comment below. It lives
+// under a directory named testdata so go build/vet/test ./... skip it (some
+// of its conversions, e.g. string(1000), are deliberately the kind go vet
+// flags); load it explicitly by path (e.g. go run . ./internal/testdata/...)
+// to dogfood the tool.
+package testdata
 
 import "github.com/jimmyfrasche/string-special-case-counter/internal/p"
 
@@ -30,7 +35,7 @@ func f() {
 	_ = string(r)
 	_ = string(p.Rune(r))
 	_ = p.String(r)
-	_ = string('Ïƒ')
+	_ = string('σ')
 	_ = string('r')
 	_ = string(1000)
 	_ = string(42)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jimmyfrasche/string-special-case-counter/stringconv"
+)
+
+// writeFixtureModule lays out a single-package module containing src and
+// returns its directory, suitable for passing to load.
+func writeFixtureModule(t *testing.T, src string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	const mod = "module fixture\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fixture.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadAndBuildReports(t *testing.T) {
+	const src = `package fixture
+
+func f(s string, bs []byte) {
+	_ = []byte(s)
+	copy(bs, []byte(s))
+}
+`
+	dir := writeFixtureModule(t, src)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	pkgs, err := load(nil, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("got %d packages, want 1", len(pkgs))
+	}
+
+	reports := buildReports(pkgs, false)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+
+	r := reports[0]
+	if got := r.Counts[stringconv.Str2Bytes]; got != 2 {
+		t.Errorf("got Str2Bytes count %d, want 2 (one standalone, one inside copy)", got)
+	}
+	if got := r.Counts[stringconv.RedundantCopy]; got != 1 {
+		t.Errorf("got RedundantCopy count %d, want 1", got)
+	}
+}
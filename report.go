@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+
+	"github.com/jimmyfrasche/string-special-case-counter/stringconv"
+)
+
+// categoryOrder fixes a stable column/print order for the categories
+// stringconv reports, since map iteration order is not.
+var categoryOrder = []stringconv.Category{
+	stringconv.Str2Bytes,
+	stringconv.Bytes2Str,
+	stringconv.Str2Runes,
+	stringconv.Rune2Str,
+	stringconv.Byte2Str,
+	stringconv.AppendStr,
+	stringconv.CopyStr,
+	stringconv.RedundantAppend,
+	stringconv.RedundantCopy,
+	stringconv.RedundantAppendChain,
+	stringconv.RedundantCopyChain,
+}
+
+// report is the per-package tally, addressable by category name so it can
+// be marshaled directly to JSON or CSV.
+type report struct {
+	Path      string                                   `json:"path"`
+	Counts    map[stringconv.Category]int              `json:"counts"`
+	LLOC      int                                      `json:"lloc"`
+	Locations map[stringconv.Category][]token.Position `json:"locations,omitempty"`
+	// ChainLocations holds both the conversion site and the sink site for
+	// RedundantAppendChain/RedundantCopyChain findings, which (unlike every
+	// other category) span two distinct positions.
+	ChainLocations map[stringconv.Category][]chainLocation `json:"chainLocations,omitempty"`
+}
+
+type chainLocation struct {
+	Conv token.Position `json:"conv"`
+	Sink token.Position `json:"sink"`
+}
+
+func newReport(path string) *report {
+	return &report{Path: path, Counts: make(map[stringconv.Category]int)}
+}
+
+// add records a finding of cat at pos, optionally keeping pos around for
+// the "locations" section of the JSON output.
+func (r *report) add(cat stringconv.Category, pos token.Position, withLocations bool) {
+	r.Counts[cat]++
+	if !withLocations {
+		return
+	}
+	if r.Locations == nil {
+		r.Locations = make(map[stringconv.Category][]token.Position)
+	}
+	r.Locations[cat] = append(r.Locations[cat], pos)
+}
+
+// addChain records a chain finding of cat, optionally keeping both its
+// conversion site and sink site for the "chainLocations" section of the
+// JSON output.
+func (r *report) addChain(cat stringconv.Category, conv, sink token.Position, withLocations bool) {
+	r.Counts[cat]++
+	if !withLocations {
+		return
+	}
+	if r.ChainLocations == nil {
+		r.ChainLocations = make(map[stringconv.Category][]chainLocation)
+	}
+	r.ChainLocations[cat] = append(r.ChainLocations[cat], chainLocation{Conv: conv, Sink: sink})
+}
+
+// summary is the final, aggregated-across-packages object.
+type summary struct {
+	Packages int                         `json:"packages"`
+	LLOC     int                         `json:"lloc"`
+	Counts   map[stringconv.Category]int `json:"counts"`
+}
+
+func summarize(reports []*report) summary {
+	s := summary{Packages: len(reports), Counts: make(map[stringconv.Category]int)}
+	for _, r := range reports {
+		s.LLOC += r.LLOC
+		for cat, n := range r.Counts {
+			s.Counts[cat] += n
+		}
+	}
+	return s
+}
+
+// printText reproduces this tool's original aggregated-tally output.
+func printText(w io.Writer, reports []*report) {
+	s := summarize(reports)
+
+	fmt.Fprintln(w, "[]byte(string):", s.Counts[stringconv.Str2Bytes])
+	fmt.Fprintln(w, "string([]byte):", s.Counts[stringconv.Bytes2Str])
+	fmt.Fprintln(w, "[]rune(string):", s.Counts[stringconv.Str2Runes])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "string(rune):", s.Counts[stringconv.Rune2Str])
+	fmt.Fprintln(w, "string(byte):", s.Counts[stringconv.Byte2Str])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "append([]byte, string...):", s.Counts[stringconv.AppendStr])
+	fmt.Fprintln(w, "copy([]byte, string):", s.Counts[stringconv.CopyStr])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "append([]byte, []byte(string)...):", s.Counts[stringconv.RedundantAppend])
+	fmt.Fprintln(w, "copy([]byte, []byte(string)):", s.Counts[stringconv.RedundantCopy])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "append([]byte, []byte(string)...) across statements:", s.Counts[stringconv.RedundantAppendChain])
+	fmt.Fprintln(w, "copy([]byte, []byte(string)) across statements:", s.Counts[stringconv.RedundantCopyChain])
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "packages examined:", s.Packages)
+	fmt.Fprintln(w, "lloc examined:", s.LLOC)
+}
+
+// printJSON emits one JSON object per package followed by a final summary
+// object, all newline-delimited so the output can be streamed/parsed line
+// by line.
+func printJSON(w io.Writer, reports []*report) error {
+	enc := json.NewEncoder(w)
+	for _, r := range reports {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return enc.Encode(summarize(reports))
+}
+
+// printCSV emits one row per package plus a final "TOTAL" row, suitable for
+// spreadsheet ingestion. Locations aren't representable as a single cell, so
+// -locations has no effect on this format.
+func printCSV(w io.Writer, reports []*report) error {
+	cw := csv.NewWriter(w)
+
+	header := append([]string{"package"}, categoryHeader()...)
+	header = append(header, "lloc")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		if err := cw.Write(csvRow(r.Path, r.Counts, r.LLOC)); err != nil {
+			return err
+		}
+	}
+
+	s := summarize(reports)
+	if err := cw.Write(csvRow("TOTAL", s.Counts, s.LLOC)); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func categoryHeader() []string {
+	header := make([]string, len(categoryOrder))
+	for i, cat := range categoryOrder {
+		header[i] = string(cat)
+	}
+	return header
+}
+
+func csvRow(path string, counts map[stringconv.Category]int, lloc int) []string {
+	row := make([]string, 0, len(categoryOrder)+2)
+	row = append(row, path)
+	for _, cat := range categoryOrder {
+		row = append(row, strconv.Itoa(counts[cat]))
+	}
+	return append(row, strconv.Itoa(lloc))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/jimmyfrasche/string-special-case-counter/stringconv"
+)
+
+func TestPrintCSV(t *testing.T) {
+	r := newReport("pkg/a")
+	r.add(stringconv.Str2Bytes, token.Position{}, false)
+	r.add(stringconv.Str2Bytes, token.Position{}, false)
+	r.add(stringconv.CopyStr, token.Position{}, false)
+	r.LLOC = 10
+
+	var buf bytes.Buffer
+	if err := printCSV(&buf, []*report{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (header, pkg/a, TOTAL): %q", len(lines), buf.String())
+	}
+
+	header := strings.Split(lines[0], ",")
+	if want := len(categoryOrder) + 2; len(header) != want {
+		t.Fatalf("header has %d columns, want %d (package + categories + lloc)", len(header), want)
+	}
+	if header[0] != "package" || header[len(header)-1] != "lloc" {
+		t.Errorf("header = %q, want to start with %q and end with %q", header, "package", "lloc")
+	}
+
+	row := strings.Split(lines[1], ",")
+	if len(row) != len(header) {
+		t.Fatalf("row has %d columns, want %d to match header", len(row), len(header))
+	}
+	if row[0] != "pkg/a" {
+		t.Errorf("row[0] = %q, want %q", row[0], "pkg/a")
+	}
+	if row[len(row)-1] != "10" {
+		t.Errorf("lloc column = %q, want %q", row[len(row)-1], "10")
+	}
+
+	total := strings.Split(lines[2], ",")
+	if total[0] != "TOTAL" {
+		t.Errorf("final row label = %q, want %q", total[0], "TOTAL")
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	r := newReport("pkg/a")
+	r.add(stringconv.Str2Bytes, token.Position{Filename: "a.go", Line: 3}, true)
+	r.LLOC = 5
+
+	var buf bytes.Buffer
+	if err := printJSON(&buf, []*report{r}); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&buf)
+
+	var got report
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("decoding package object: %v", err)
+	}
+	if got.Path != "pkg/a" || got.LLOC != 5 {
+		t.Errorf("got %+v, want Path %q LLOC 5", got, "pkg/a")
+	}
+	if got.Counts[stringconv.Str2Bytes] != 1 {
+		t.Errorf("got Counts[Str2Bytes] = %d, want 1", got.Counts[stringconv.Str2Bytes])
+	}
+	if len(got.Locations[stringconv.Str2Bytes]) != 1 || got.Locations[stringconv.Str2Bytes][0].Line != 3 {
+		t.Errorf("got Locations[Str2Bytes] = %+v, want one position on line 3", got.Locations[stringconv.Str2Bytes])
+	}
+
+	var sum summary
+	if err := dec.Decode(&sum); err != nil {
+		t.Fatalf("decoding summary object: %v", err)
+	}
+	if sum.Packages != 1 || sum.LLOC != 5 || sum.Counts[stringconv.Str2Bytes] != 1 {
+		t.Errorf("got summary %+v, want Packages 1 LLOC 5 Counts[Str2Bytes] 1", sum)
+	}
+}
@@ -0,0 +1,130 @@
+package stringconv
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Chain is a redundant []byte(string) conversion whose result is consumed by
+// append or copy in a different statement than the one that produced it,
+// e.g.
+//
+//	tmp := []byte(s)
+//	copy(bs, tmp)
+//
+// Unlike the AST-only checks in this package, which only see a conversion
+// that appears directly as a call argument, Chain is found by following the
+// conversion's value through SSA form, so it also catches round-trips that
+// cross statement (and even basic block) boundaries.
+type Chain struct {
+	Category Category // RedundantAppendChain or RedundantCopyChain
+	Conv     token.Pos
+	Sink     token.Pos
+}
+
+// FindChains reports the Chains in the SSA-built packages pkgs. pkgs must
+// have been built (see (*ssa.Program).Build) from the same go/packages
+// result that produced the type information used elsewhere in this package,
+// and fset must be the corresponding token.FileSet.
+func FindChains(fset *token.FileSet, pkgs []*ssa.Package) []Chain {
+	var out []Chain
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		for _, mem := range pkg.Members {
+			fn, ok := mem.(*ssa.Function)
+			if !ok {
+				continue
+			}
+			out = append(out, chainsInFunc(fset, fn)...)
+		}
+	}
+	return out
+}
+
+func chainsInFunc(fset *token.FileSet, fn *ssa.Function) []Chain {
+	var out []Chain
+
+	for _, anon := range fn.AnonFuncs {
+		out = append(out, chainsInFunc(fset, anon)...)
+	}
+
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			conv, ok := instr.(*ssa.Convert)
+			if !ok || !isStr2Bytes(conv) {
+				continue
+			}
+			cat, sink, ok := walkToSink(fset, conv, conv)
+			if !ok {
+				continue
+			}
+			out = append(out, Chain{Category: cat, Conv: conv.Pos(), Sink: sink})
+		}
+	}
+
+	return out
+}
+
+func isStr2Bytes(conv *ssa.Convert) bool {
+	return KindOf(conv.X.Type()) == String && KindOf(conv.Type()) == Bytes
+}
+
+// sameStmt reports whether a and b lie on the same source line of the same
+// file, which is true precisely when a and b came from the same statement in
+// the cases this package looks at (a single-expression round-trip), as
+// opposed to a round-trip spread across statements.
+func sameStmt(fset *token.FileSet, a, b token.Pos) bool {
+	pa, pb := fset.Position(a), fset.Position(b)
+	return pa.Filename == pb.Filename && pa.Line == pb.Line
+}
+
+// walkToSink follows the single use-def chain rooted at root forward through
+// any further []byte-preserving Convert or Slice instructions, looking for a
+// terminal append or copy call that consumes val directly. It reports false
+// as soon as val is used more than once (so the rewrite can't be shown safe
+// by local reasoning alone), used somewhere that isn't part of the chain, or
+// the sink turns out to be on the same line as root (already caught by the
+// AST-only checks above).
+func walkToSink(fset *token.FileSet, val ssa.Value, root *ssa.Convert) (Category, token.Pos, bool) {
+	refs := val.Referrers()
+	if refs == nil || len(*refs) != 1 {
+		return "", 0, false
+	}
+
+	switch instr := (*refs)[0].(type) {
+	case *ssa.Convert:
+		if KindOf(instr.Type()) != Bytes {
+			return "", 0, false
+		}
+		return walkToSink(fset, instr, root)
+
+	case *ssa.Slice:
+		if KindOf(instr.Type()) != Bytes {
+			return "", 0, false
+		}
+		return walkToSink(fset, instr, root)
+
+	case *ssa.Call:
+		blt, ok := instr.Call.Value.(*ssa.Builtin)
+		if !ok || len(instr.Call.Args) != 2 || instr.Call.Args[1] != val {
+			return "", 0, false
+		}
+		if KindOf(instr.Call.Args[0].Type()) != Bytes {
+			return "", 0, false
+		}
+		if sameStmt(fset, instr.Pos(), root.Pos()) {
+			return "", 0, false // already caught by the AST pass
+		}
+		switch blt.Name() {
+		case "append":
+			return RedundantAppendChain, instr.Pos(), true
+		case "copy":
+			return RedundantCopyChain, instr.Pos(), true
+		}
+	}
+
+	return "", 0, false
+}
@@ -0,0 +1,84 @@
+package stringconv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+
+	"github.com/jimmyfrasche/string-special-case-counter/stringconv"
+)
+
+// loadFixture builds and loads a single-file module containing src, with
+// enough information (types, syntax, deps) to build its SSA form.
+func loadFixture(t *testing.T, src string) *packages.Package {
+	t.Helper()
+
+	dir := t.TempDir()
+	const mod = "module fixture\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fixture.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &packages.Config{
+		Dir: dir,
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedTypes |
+			packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedDeps,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("got %d packages, want 1", len(pkgs))
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		t.Fatal("fixture package has errors")
+	}
+	return pkgs[0]
+}
+
+// findChains builds the SSA form of pkg and returns the Chains found in it.
+func findChains(t *testing.T, pkg *packages.Package) []stringconv.Chain {
+	t.Helper()
+
+	prog, ssaPkgs := ssautil.Packages([]*packages.Package{pkg}, ssa.BuilderMode(0))
+	prog.Build()
+	return stringconv.FindChains(pkg.Fset, ssaPkgs)
+}
+
+func TestFindChainsCrossStatement(t *testing.T) {
+	const src = `package fixture
+
+func f(s string, bs []byte) {
+	tmp := []byte(s)
+	copy(bs, tmp)
+}
+`
+	chains := findChains(t, loadFixture(t, src))
+	if len(chains) != 1 {
+		t.Fatalf("got %d chains, want 1: %+v", len(chains), chains)
+	}
+	if chains[0].Category != stringconv.RedundantCopyChain {
+		t.Errorf("got category %s, want %s", chains[0].Category, stringconv.RedundantCopyChain)
+	}
+}
+
+func TestFindChainsSameLineExcluded(t *testing.T) {
+	const src = `package fixture
+
+func f(s string, bs []byte) {
+	copy(bs, []byte(s))
+}
+`
+	chains := findChains(t, loadFixture(t, src))
+	if len(chains) != 0 {
+		t.Fatalf("got %d chains, want 0 (already caught by the AST pass): %+v", len(chains), chains)
+	}
+}
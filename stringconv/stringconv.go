@@ -0,0 +1,350 @@
+// Package stringconv defines an Analyzer that reports conversions between
+// string and its byte/rune counterparts that could instead be written as a
+// call to append or copy, and conversions that are already inside such a
+// call and so are entirely redundant.
+package stringconv
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const Doc = `check for unnecessary string/[]byte/[]rune/byte/rune conversions
+
+The stringconv analyzer reports:
+	- conversions of a string to []byte, []rune, or a rune/byte conversion
+	  back to string that could instead be written as an append or copy
+	  into an existing []byte, and
+	- conversions that already occur as the source of such an append or
+	  copy, which are entirely redundant since append and copy both accept
+	  a string directly.`
+
+// Analyzer reports the patterns described in Doc. Categories (e.g. for
+// filtering or aggregating diagnostics) are exposed as the Category consts
+// below and set as each Diagnostic's Category field.
+var Analyzer = &analysis.Analyzer{
+	Name:     "stringconv",
+	Doc:      Doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// Category identifies which of the patterns stringconv looks for a
+// particular diagnostic came from.
+type Category string
+
+const (
+	Str2Bytes       Category = "str2bs"          // []byte(string)
+	Bytes2Str       Category = "bs2str"          // string([]byte)
+	Str2Runes       Category = "str2rs"          // []rune(string)
+	Rune2Str        Category = "r2str"           // string(rune)
+	Byte2Str        Category = "b2str"           // string(byte)
+	AppendStr       Category = "appendStr"       // append([]byte, string...)
+	CopyStr         Category = "copyStr"         // copy([]byte, string)
+	RedundantAppend Category = "redundantAppend" // append([]byte, []byte(string)...)
+	RedundantCopy   Category = "redundantCopy"   // copy([]byte, []byte(string))
+
+	// RedundantAppendChain and RedundantCopyChain are the multi-statement
+	// counterparts of RedundantAppend and RedundantCopy, found only by the
+	// SSA-backed pass in FindChains: the []byte(string) conversion and the
+	// append/copy consuming it occur in different statements, e.g.
+	//	tmp := []byte(s)
+	//	copy(bs, tmp)
+	RedundantAppendChain Category = "redundantAppendChain"
+	RedundantCopyChain   Category = "redundantCopyChain"
+)
+
+// Kind classifies a type as one of the string-like types stringconv cares
+// about.
+type Kind uint
+
+const (
+	Other Kind = iota
+	String
+	Bytes
+	Runes
+	Byte
+	Rune
+)
+
+func (k Kind) String() string {
+	switch k {
+	case String:
+		return "string"
+	case Bytes:
+		return "[]byte"
+	case Runes:
+		return "[]rune"
+	case Byte:
+		return "byte"
+	case Rune:
+		return "rune"
+	default:
+		return "<other>"
+	}
+}
+
+// KindOf reports the Kind of t, or Other if t is not one of the types
+// stringconv cares about.
+func KindOf(t types.Type) Kind {
+	switch t := t.(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String, types.UntypedString:
+			return String
+		case types.Byte:
+			return Byte
+		case types.Rune, types.UntypedRune, types.UntypedInt:
+			// NB, UntypedInt catches things like string(42).
+			// This would cause false positives except that we only examine
+			// in specific cases where a false positive would be illegal since
+			// the program type checks.
+			return Rune
+		default:
+			return Other
+		}
+
+	case *types.Slice:
+		switch KindOf(t.Elem()) {
+		case Byte:
+			return Bytes
+		case Rune:
+			return Runes
+		default:
+			return Other
+		}
+
+	case *types.Named:
+		return KindOf(t.Underlying())
+
+	default:
+		return Other
+	}
+}
+
+// isConversion tests whether n is a conversion and returns the type being
+// converted to. This misses a number of cases but covers all that this
+// analyzer cares about.
+func isConversion(info *types.Info, n ast.Node) (types.Type, ast.Expr, bool) {
+	call, ok := n.(*ast.CallExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	if len(call.Args) != 1 {
+		return nil, nil, false
+	}
+	if call.Ellipsis != token.NoPos {
+		return nil, nil, false
+	}
+
+	to := info.Types[call.Fun].Type
+
+	conv := astutil.Unparen(call.Fun)
+
+	// Only interested in the y from x.y and only if x is a package.
+	if se, ok := conv.(*ast.SelectorExpr); ok {
+		if info.Selections[se] != nil {
+			return nil, nil, false
+		}
+
+		conv = se.Sel
+	}
+
+	switch F := conv.(type) {
+	case *ast.ArrayType, *ast.StarExpr: // NB. StarExpr for weird ones like *(*string)(&x)
+		// Must be a conversion.
+
+	case *ast.Ident:
+		switch to := to.(type) {
+		case *types.Basic:
+			// If this is something we care about, this is string.
+		case *types.Named:
+			// Accept if the name is the same as the same type
+			if to.Obj().Name() != F.Name {
+				return nil, nil, false
+			}
+
+		default:
+			return nil, nil, false
+		}
+
+	default:
+		return nil, nil, false
+	}
+
+	return to, call.Args[0], true
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	insp.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if builtin(pass, call) {
+			return
+		}
+		conversion(pass, call)
+	})
+
+	return nil, nil
+}
+
+// builtin reports a diagnostic for calls to append or copy that could
+// consume a string directly, or already do despite wrapping it in a
+// redundant []byte(...) conversion. It reports whether call was one of
+// these two builtins.
+func builtin(pass *analysis.Pass, call *ast.CallExpr) bool {
+	id, ok := call.Fun.(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	bi, ok := pass.TypesInfo.Uses[id]
+	if !ok {
+		return false
+	}
+
+	isAppend := false
+	switch bi.Name() {
+	case "append":
+		// Only want append(X, Y...).
+		if len(call.Args) != 2 || call.Ellipsis == token.NoPos {
+			return false
+		}
+		isAppend = true
+
+	case "copy":
+
+	default:
+		return false
+	}
+	// In either case, need arg₀ = []byte, arg₁ = string or []byte(string).
+	if KindOf(pass.TypesInfo.Types[call.Args[0]].Type) != Bytes {
+		return false
+	}
+
+	k := KindOf(pass.TypesInfo.Types[call.Args[1]].Type)
+	if k == Bytes {
+		// Redundant []byte(string): the conversion can be dropped since
+		// append/copy already accept a string in this position.
+		_, arg, ok := isConversion(pass.TypesInfo, call.Args[1])
+		if !ok {
+			return false
+		}
+		if KindOf(pass.TypesInfo.Types[arg].Type) != String {
+			return false
+		}
+
+		if isAppend {
+			report(pass, call, RedundantAppend, "append argument is already a string; the []byte(...) conversion is redundant", dropConversion(pass, call.Args[1], arg))
+		} else {
+			report(pass, call, RedundantCopy, "copy source is already a string; the []byte(...) conversion is redundant", dropConversion(pass, call.Args[1], arg))
+		}
+		return true
+	}
+	if k != String {
+		return false
+	}
+
+	if isAppend {
+		report(pass, call, AppendStr, "append([]byte, string...) can be used directly; no need to convert to []rune or []byte first", nil)
+	} else {
+		report(pass, call, CopyStr, "copy([]byte, string) can be used directly; no need to convert to []byte first", nil)
+	}
+	return true
+}
+
+func conversion(pass *analysis.Pass, call *ast.CallExpr) {
+	toType, _, ok := isConversion(pass.TypesInfo, call)
+	if !ok {
+		return
+	}
+
+	to := KindOf(toType)
+	if to == Other {
+		return
+	}
+
+	from := KindOf(pass.TypesInfo.Types[call.Args[0]].Type)
+	if from == Other {
+		return
+	}
+
+	switch to {
+	case String:
+		switch from {
+		case Byte:
+			report(pass, call, Byte2Str, "string(byte) conversion", identityFix(pass, call, Byte))
+		case Rune:
+			report(pass, call, Rune2Str, "string(rune) conversion", identityFix(pass, call, Rune))
+		case Bytes:
+			report(pass, call, Bytes2Str, "string([]byte) conversion", identityFix(pass, call, Bytes))
+		}
+
+	case Bytes:
+		if from == String {
+			report(pass, call, Str2Bytes, "[]byte(string) conversion", identityFix(pass, call, String))
+		}
+
+	case Runes:
+		if from == String {
+			report(pass, call, Str2Runes, "[]rune(string) conversion", identityFix(pass, call, String))
+		}
+	}
+}
+
+// identityFix looks for calls of the shape T(U(x)) where U(x) is itself a
+// conversion (as opposed to a function call) and x's Kind is already want —
+// e.g. []byte(p.String(s)) where p.String is a named type whose underlying
+// type is string. Since a conversion between two types of the same Kind
+// never changes the value's representation, U(x) can only be there for its
+// static type, so T(U(x)) can be rewritten as T(x) without changing the
+// program's behavior.
+func identityFix(pass *analysis.Pass, call *ast.CallExpr, want Kind) *analysis.SuggestedFix {
+	_, arg, ok := isConversion(pass.TypesInfo, call.Args[0])
+	if !ok {
+		return nil
+	}
+	if KindOf(pass.TypesInfo.Types[arg].Type) != want {
+		return nil
+	}
+	return dropConversion(pass, call.Args[0], arg)
+}
+
+func report(pass *analysis.Pass, call *ast.CallExpr, cat Category, message string, fix *analysis.SuggestedFix) {
+	d := analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: string(cat),
+		Message:  message,
+	}
+	if fix != nil {
+		d.SuggestedFixes = []analysis.SuggestedFix{*fix}
+	}
+	pass.Report(d)
+}
+
+// dropConversion builds a SuggestedFix that replaces the conversion
+// expression full (e.g. []byte(arg)) with the text of arg, e.g. turning
+// copy(bs, []byte(s)) into copy(bs, s) and append(bs, []byte(s)...) into
+// append(bs, s...).
+func dropConversion(pass *analysis.Pass, full ast.Expr, arg ast.Expr) *analysis.SuggestedFix {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, pass.Fset, arg); err != nil {
+		return nil
+	}
+	return &analysis.SuggestedFix{
+		Message: "remove redundant conversion",
+		TextEdits: []analysis.TextEdit{
+			{Pos: full.Pos(), End: full.End(), NewText: buf.Bytes()},
+		},
+	}
+}
@@ -0,0 +1,17 @@
+package stringconv_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/jimmyfrasche/string-special-case-counter/stringconv"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), stringconv.Analyzer, "a")
+}
+
+func TestAnalyzerSuggestedFixes(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, analysistest.TestData(), stringconv.Analyzer, "b")
+}
@@ -0,0 +1,24 @@
+// Package a exercises every diagnostic category stringconv.Analyzer reports.
+package a
+
+func f() {
+	var bs []byte
+	var s string
+	var b byte
+	var r rune
+
+	_ = []byte(s)  // want `\[\]byte\(string\) conversion`
+	_ = string(bs) // want `string\(\[\]byte\) conversion`
+	_ = []rune(s)  // want `\[\]rune\(string\) conversion`
+	_ = string(r)  // want `string\(rune\) conversion`
+	_ = string(b)  // want `string\(byte\) conversion`
+
+	_ = append(bs, s...) // want `append\(\[\]byte, string\.\.\.\) can be used directly; no need to convert to \[\]rune or \[\]byte first`
+	copy(bs, s)          // want `copy\(\[\]byte, string\) can be used directly; no need to convert to \[\]byte first`
+
+	// Each of these also reports a plain Str2Bytes diagnostic for the inner
+	// []byte(s), since that's a distinct *ast.CallExpr from the outer
+	// append/copy that also happens to consume it.
+	_ = append(bs, []byte(s)...) // want `\[\]byte\(string\) conversion` `append argument is already a string; the \[\]byte\(\.\.\.\) conversion is redundant`
+	copy(bs, []byte(s))          // want `\[\]byte\(string\) conversion` `copy source is already a string; the \[\]byte\(\.\.\.\) conversion is redundant`
+}
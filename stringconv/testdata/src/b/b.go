@@ -0,0 +1,13 @@
+// Package b exercises stringconv.Analyzer's SuggestedFixes.
+package b
+
+type myString string
+
+func f() {
+	var bs []byte
+	var s string
+
+	_ = []byte(myString(s))      // want `\[\]byte\(string\) conversion`
+	_ = append(bs, []byte(s)...) // want `\[\]byte\(string\) conversion` `append argument is already a string; the \[\]byte\(\.\.\.\) conversion is redundant`
+	copy(bs, []byte(s))          // want `\[\]byte\(string\) conversion` `copy source is already a string; the \[\]byte\(\.\.\.\) conversion is redundant`
+}